@@ -0,0 +1,64 @@
+package main
+
+// Options holds the CLI configuration for the vipnode pool command. It's
+// populated by go-flags from the command line before runPool is called.
+type Options struct {
+	Pool struct {
+		// Bind is the host:port the pool's JSON-RPC server listens on.
+		Bind string `long:"bind" default:":8080" description:"Host:port to listen on."`
+
+		// TLSHost, if set, requests an ACME certificate for this hostname
+		// and serves over TLS on :443 instead of Bind.
+		TLSHost string `long:"tlshost" description:"Hostname to request an ACME (Let's Encrypt) certificate for, serving over TLS on :443."`
+
+		// AllowOrigin sets Access-Control-Allow-Origin on every response,
+		// for dashboards served from a different origin than the pool.
+		AllowOrigin string `long:"allow-origin" description:"Access-Control-Allow-Origin header value to send on every response."`
+
+		// DataDir is where the badger store keeps its files. Empty uses the
+		// platform's default XDG data directory.
+		DataDir string `long:"datadir" description:"Directory to store persistent data in. Defaults to the platform's XDG data directory."`
+
+		// Store selects the storage driver: "memory", "badger" (aliased as
+		// "persist"), or "postgres".
+		Store string `long:"pool-store" default:"memory" description:"Storage driver to use: memory, badger, or postgres."`
+
+		// StoreDSN is the postgres connection string, required when Store
+		// is "postgres".
+		StoreDSN string `long:"pool-store-dsn" description:"Postgres connection string. Required when --pool-store=postgres."`
+
+		// ContractAddr is a repeatable "network://0xAddress" value, one per
+		// network this pool should serve a payment contract for.
+		ContractAddr []string `long:"pool-contract-addr" description:"Payment contract to serve, as \"network://0xAddress\" (e.g. \"mainnet://0x...\"). Repeatable for multiple networks."`
+
+		// ContractRPC is a repeatable "network=url" value, pairing each
+		// ContractAddr entry's network with the RPC endpoint to dial it on.
+		ContractRPC []string `long:"pool-contract-rpc" description:"Ethereum RPC endpoint for a network, as \"network=url\" (e.g. \"mainnet=https://mainnet.infura.io\"). Repeatable for multiple networks."`
+
+		// ContractKeyStore is the legacy keystore-file-only way to
+		// configure the contract operator's signer, decrypted with the
+		// KEYSTORE_PASSPHRASE env var. Superseded by ContractSigner, which
+		// is preferred when both are set.
+		ContractKeyStore string `long:"contract-keystore" description:"Path to a JSON keystore file for the contract operator. Deprecated, use --contract-signer=keystore://path instead."`
+
+		// ContractSigner selects how transactions are signed for the
+		// contract operator: keystore://path, clef://host-or-path, or
+		// ledger://m/44'/60'/0'/0/0 (see resolveTransactor).
+		ContractSigner string `long:"contract-signer" description:"Signer to use for contract transactions: keystore://path, clef://host-or-ipc-path, or ledger://derivation-path."`
+
+		// WithdrawGasEstimate, WithdrawFeeMultiplier, and WithdrawFeeCeiling
+		// drive the withdraw fee gas oracle (see payment.GasOracle).
+		// WithdrawMin is the minimum withdrawable balance passed to the
+		// payment service; it does not affect the oracle's fee floor.
+		// WithdrawMin and WithdrawFeeCeiling are decimal wei amounts; empty
+		// means "use the built-in default".
+		WithdrawGasEstimate   uint64  `long:"withdraw-gas-estimate" default:"21000" description:"Gas a settlement transaction is expected to consume, used to compute the withdraw fee."`
+		WithdrawFeeMultiplier float64 `long:"withdraw-fee-multiplier" default:"1.2" description:"Multiplier applied to the sampled gas price when computing the withdraw fee."`
+		WithdrawMin           string  `long:"withdraw-min" description:"Minimum withdrawable balance, in wei. Defaults to 0."`
+		WithdrawFeeCeiling    string  `long:"withdraw-fee-ceiling" description:"Maximum withdraw fee that can be charged, in wei. Defaults to 0.01 ETH."`
+
+		// MetricsBind, if set, mounts a Prometheus /metrics handler on this
+		// host:port.
+		MetricsBind string `long:"metrics-bind" description:"Host:port to serve Prometheus metrics on at /metrics. Leave empty to disable."`
+	} `command:"pool" description:"Run a vipnode pool server."`
+}
@@ -13,19 +13,48 @@ import (
 
 	"github.com/OpenPeeDeeP/xdg"
 	"github.com/dgraph-io/badger"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/vipnode/vipnode/ethnode"
 	ws "github.com/vipnode/vipnode/jsonrpc2/ws/gorilla"
 	"github.com/vipnode/vipnode/pool"
 	"github.com/vipnode/vipnode/pool/balance"
+	"github.com/vipnode/vipnode/pool/metrics"
 	"github.com/vipnode/vipnode/pool/payment"
 	"github.com/vipnode/vipnode/pool/store"
 	badgerStore "github.com/vipnode/vipnode/pool/store/badger"
+	postgresStore "github.com/vipnode/vipnode/pool/store/postgres"
 	"golang.org/x/crypto/acme/autocert"
 )
 
+// defaultWithdrawFeeFloor is the minimum withdraw fee charged regardless of
+// the sampled gas price, so a misbehaving oracle can't zero out the fee.
+var defaultWithdrawFeeFloor = big.NewInt(1000000000000000) // 0.001 ETH
+
+// splitNetworkFlag parses a repeatable "network=value" flag entry, as used
+// by --pool-contract-rpc.
+func splitNetworkFlag(entry string) (network, value string, err error) {
+	i := strings.IndexByte(entry, '=')
+	if i < 0 {
+		return "", "", fmt.Errorf("expected \"network=value\", got %q", entry)
+	}
+	return entry[:i], entry[i+1:], nil
+}
+
+// parseWei parses a decimal wei amount from a CLI flag value such as
+// --withdraw-min or --withdraw-fee-ceiling. An empty value parses to nil, so
+// callers can tell "not configured" apart from an explicit 0 and fall back
+// to their own default.
+func parseWei(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil, fmt.Errorf("expected a decimal wei amount, got %q", value)
+	}
+	return amount, nil
+}
+
 // findDataDir returns a valid data dir, will create it if it doesn't
 // exist.
 func findDataDir(overridePath string) (string, error) {
@@ -37,7 +66,110 @@ func findDataDir(overridePath string) (string, error) {
 	return path, err
 }
 
+// storeSizePollInterval is how often StoreSize is refreshed for stores that
+// support reporting their own size.
+const storeSizePollInterval = 30 * time.Second
+
+// sizer is implemented by store drivers that can report their on-disk size,
+// e.g. postgresStore.Store. It's optional: stores that don't implement it
+// just leave metrics.StoreSize at 0.
+type sizer interface {
+	Size(ctx context.Context) (int64, error)
+}
+
+// pollStoreSize periodically updates metrics.StoreSize from storeDriver, if
+// it implements sizer, until ctx is done.
+func pollStoreSize(ctx context.Context, storeDriver store.Store) {
+	s, ok := storeDriver.(sizer)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(storeSizePollInterval)
+	defer ticker.Stop()
+	for {
+		sizeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		size, err := s.Size(sizeCtx)
+		cancel()
+		if err != nil {
+			logger.Warningf("Failed to sample store size for metrics: %s", err)
+		} else {
+			metrics.StoreSize.Set(float64(size))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// meteredBalanceStore wraps a store.BalanceStore so every credit applied
+// through it (i.e. every interval payout from balance.PayPerInterval) is
+// reflected in metrics.BalanceCredited.
+type meteredBalanceStore struct {
+	store.BalanceStore
+}
+
+func (m meteredBalanceStore) AddBalance(address string, amount *big.Int) (*big.Int, error) {
+	balance, err := m.BalanceStore.AddBalance(address, amount)
+	if err == nil && amount.Sign() > 0 {
+		credited, _ := new(big.Float).SetInt(amount).Float64()
+		metrics.BalanceCredited.Add(credited)
+	}
+	return balance, err
+}
+
+// errNetworkRoutingRequired is returned by networkRoutingRequiredBalanceStore
+// for every call.
+var errNetworkRoutingRequired = errors.New("interval balance credit requires a network-qualified address, but the host's declared network isn't known at this point; configure only one --pool-contract-addr network, or settle manually per network")
+
+// networkRoutingRequiredBalanceStore is used in place of a real
+// store.BalanceStore when multiple --pool-contract-addr networks are
+// configured and interval credits (which only have a host's plain address,
+// not its declared network) can't be routed to the right one. It fails
+// loudly instead of crediting every host against an arbitrarily-chosen
+// network's contract.
+type networkRoutingRequiredBalanceStore struct{}
+
+func (networkRoutingRequiredBalanceStore) Balance(address string) (*big.Int, error) {
+	return nil, errNetworkRoutingRequired
+}
+
+func (networkRoutingRequiredBalanceStore) AddBalance(address string, amount *big.Int) (*big.Int, error) {
+	return nil, errNetworkRoutingRequired
+}
+
+// instrumentSettle wraps settle so every call counts towards
+// metrics.SettleAttempts and metrics.SettleLatency, and metrics.SettleFailures
+// too if it returns a non-nil error.
+func instrumentSettle(settle payment.SettleHandler) payment.SettleHandler {
+	if settle == nil {
+		return nil
+	}
+	return func(address string, amount *big.Int) error {
+		defer metrics.ObserveSettleLatency()()
+		metrics.SettleAttempts.Inc()
+		err := settle(address, amount)
+		if err != nil {
+			metrics.SettleFailures.Inc()
+		}
+		return err
+	}
+}
+
 func runPool(options Options) error {
+	withdrawFeeCeiling, err := parseWei(options.Pool.WithdrawFeeCeiling)
+	if err != nil {
+		return ErrExplain{err, "Invalid --withdraw-fee-ceiling value."}
+	}
+	withdrawMin, err := parseWei(options.Pool.WithdrawMin)
+	if err != nil {
+		return ErrExplain{err, "Invalid --withdraw-min value."}
+	}
+	if withdrawMin == nil {
+		withdrawMin = big.NewInt(0)
+	}
+
 	var storeDriver store.Store
 	switch options.Pool.Store {
 	case "memory":
@@ -59,70 +191,130 @@ func runPool(options Options) error {
 		}
 		defer storeDriver.Close()
 		logger.Infof("Persistent store using badger backend: %s", dir)
+	case "postgres":
+		var err error
+		storeDriver, err = postgresStore.Open(options.Pool.StoreDSN)
+		if err != nil {
+			return ErrExplain{err, "Failed to connect to the postgres store. Check --pool-store-dsn."}
+		}
+		defer storeDriver.Close()
+		logger.Infof("Persistent store using postgres backend")
 	default:
 		return errors.New("storage driver not implemented")
 	}
 
 	balanceStore := store.BalanceStore(storeDriver)
-	var settleHandler payment.SettleHandler
-	if options.Pool.ContractAddr != "" {
-		// Payment contract implements NodeBalanceStore used by the balance
-		// manager, but with contract awareness.
-		contractPath, err := url.Parse(options.Pool.ContractAddr)
-		if err != nil {
-			return err
+	var router *payment.NetworkRouter
+	gasOracleByNetwork := map[string]*payment.GasOracle{}
+	if len(options.Pool.ContractAddr) > 0 {
+		// --pool-contract-rpc is keyed by network (e.g. "mainnet=https://...")
+		// so each contract dials the RPC endpoint for its own network.
+		rpcByNetwork := map[string]string{}
+		for _, entry := range options.Pool.ContractRPC {
+			network, rpc, err := splitNetworkFlag(entry)
+			if err != nil {
+				return ErrExplain{err, "Failed to parse --pool-contract-rpc. Expected \"network=url\", e.g. \"mainnet=https://mainnet.infura.io\"."}
+			}
+			rpcByNetwork[network] = rpc
 		}
 
-		contractAddr := common.HexToAddress(contractPath.Hostname())
-		network := contractPath.Scheme
-		ethclient, err := ethclient.Dial(options.Pool.ContractRPC)
-		if err != nil {
-			return err
+		signerURL := options.Pool.ContractSigner
+		if signerURL == "" && options.Pool.ContractKeyStore != "" {
+			// Back-compat with the old --contract-keystore flag.
+			signerURL = "keystore://" + options.Pool.ContractKeyStore
 		}
-
-		// Confirm we're on the right network
-		gotNetwork, err := ethclient.NetworkID(context.Background())
+		transactOpts, err := resolveTransactor(signerURL)
 		if err != nil {
 			return err
 		}
-		if networkID := ethnode.NetworkID(int(gotNetwork.Int64())); !networkID.Is(network) {
-			return ErrExplain{
-				errors.New("ethereum network mismatch for payment contract"),
-				fmt.Sprintf("Contract is on %q while the Contact RPC is a %q node. Please provide a Contract RPC on the same network as the contract.", network, networkID),
-			}
+		if transactOpts == nil {
+			logger.Warningf("Contract payment starting in read-only mode because --contract-signer was not set. Withdraw and settlement attempts will fail.")
 		}
 
-		var transactOpts *bind.TransactOpts
-		if options.Pool.ContractKeyStore != "" {
-			transactOpts, err = unlockTransactor(options.Pool.ContractKeyStore)
+		// --pool-contract-addr is a repeatable "network://0xAddress" value,
+		// one per network this pool should serve. Each network's contract
+		// is dialed, confirmed, and merged into a NetworkRouter below.
+		var contracts []payment.NetworkContract
+		for _, entry := range options.Pool.ContractAddr {
+			contractPath, err := url.Parse(entry)
 			if err != nil {
+				return ErrExplain{err, fmt.Sprintf("Failed to parse --pool-contract-addr value %q.", entry)}
+			}
+			network := contractPath.Scheme
+			contractAddr := common.HexToAddress(contractPath.Hostname())
+
+			rpc, ok := rpcByNetwork[network]
+			if !ok {
 				return ErrExplain{
-					err,
-					"Failed to unlock the keystore for the contract operator wallet. Make sure the path is correct and the decryption password is set in the `KEYSTORE_PASSPHRASE` environment variable.",
+					fmt.Errorf("no --pool-contract-rpc provided for network %q", network),
+					fmt.Sprintf("Add a --pool-contract-rpc entry for %q matching its --pool-contract-addr.", network),
 				}
 			}
-		}
+			client, err := ethclient.Dial(rpc)
+			if err != nil {
+				return err
+			}
+			if err := payment.CheckNetworkID(client, network); err != nil {
+				return ErrExplain{err, "Please provide a Contract RPC on the same network as the contract."}
+			}
 
-		if transactOpts == nil {
-			logger.Warningf("Contract payment starting in read-only mode because --contract-keystore was not set. Withdraw and settlement attempts will fail.")
+			contract, err := payment.ContractPayment(storeDriver, contractAddr, client, transactOpts)
+			if err != nil {
+				if err, ok := err.(payment.AddressMismatchError); ok {
+					return ErrExplain{
+						err,
+						"Contract keystore must match the wallet of the contract operator. Make sure you're providing the correct keystore.",
+					}
+				}
+				return err
+			}
+			contracts = append(contracts, payment.NetworkContract{Network: network, Contract: contract})
+
+			// Each network gets its own gas oracle, since gas prices are
+			// independent per chain.
+			gasOracle := &payment.GasOracle{
+				Client:         client,
+				SampleInterval: 1 * time.Minute,
+				GasEstimate:    options.Pool.WithdrawGasEstimate,
+				FeeMultiplier:  options.Pool.WithdrawFeeMultiplier,
+				Min:            defaultWithdrawFeeFloor,
+				Max:            withdrawFeeCeiling,
+			}
+			if err := gasOracle.Start(context.Background()); err != nil {
+				return ErrExplain{err, "Failed to start the withdraw fee gas oracle. Make sure the Contract RPC is reachable."}
+			}
+			gasOracleByNetwork[network] = gasOracle
 		}
 
-		contract, err := payment.ContractPayment(storeDriver, contractAddr, ethclient, transactOpts)
+		router, err = payment.NewNetworkRouter(contracts)
 		if err != nil {
-			if err, ok := err.(payment.AddressMismatchError); ok {
-				return ErrExplain{
-					err,
-					"Contract keystore must match the wallet of the contract operator. Make sure you're providing the correct keystore.",
-				}
-			}
-			return err
+			return ErrExplain{err, "Overlapping contract addresses across --pool-contract-addr networks."}
 		}
-		balanceStore = contract
-		settleHandler = contract.OpSettle
+		if len(router.Networks()) == 1 {
+			// Only one network configured, so there's no ambiguity: every
+			// host's interval credit goes to that network's contract.
+			balanceStore = router.Networks()[0].Contract
+		} else {
+			// balanceManager below credits hosts by their plain address,
+			// with no network to qualify it by: router.AddBalance requires
+			// a "network:0xaddress" key (see NetworkAddress/
+			// splitNetworkAddress), and nothing between here and the
+			// host-connect handshake currently threads a host's declared
+			// network through to the crediting path. Crediting every host
+			// against a single arbitrarily-chosen network's contract would
+			// silently route money to the wrong chain for any host on a
+			// secondary network, so refuse interval credits outright until
+			// that handshake state is plumbed through, rather than guess.
+			logger.Warningf("Multiple --pool-contract-addr networks configured; interval balance credits cannot be routed to the right network yet and will fail with a logged error until this is implemented. Per-settlement payments are unaffected.")
+			balanceStore = networkRoutingRequiredBalanceStore{}
+		}
+	}
+	for _, gasOracle := range gasOracleByNetwork {
+		defer gasOracle.Stop()
 	}
 
 	balanceManager := balance.PayPerInterval(
-		balanceStore,
+		meteredBalanceStore{balanceStore},
 		time.Minute*1,    // Interval
 		big.NewInt(1000), // Credit per interval
 	)
@@ -141,22 +333,70 @@ func runPool(options Options) error {
 		return err
 	}
 
-	// Pool payment management API (optional)
-	payment := &payment.PaymentService{
-		NonceStore:   storeDriver,
-		AccountStore: storeDriver,
-		BalanceStore: balanceStore, // Proxy smart contract store if available
+	// Pool payment management API (optional). With no contract configured,
+	// this only handles bookkeeping (nonces/accounts) against the base
+	// store. With one or more networks configured, one PaymentService is
+	// registered per network so clients settle against the chain they
+	// actually declared. The first configured network is also registered
+	// bare on "pool_" for backwards compatibility with single-network pools.
+	withdrawFee := func(network string) func(amount *big.Int) *big.Int {
+		return func(amount *big.Int) *big.Int {
+			gasOracle, ok := gasOracleByNetwork[network]
+			if !ok {
+				// No contract configured for this network, so there's no
+				// settlement gas cost to recover.
+				return amount
+			}
+			remaining := gasOracle.Fee(amount)
+			logger.Debugf("Effective withdraw fee (%s): %s wei", network, new(big.Int).Sub(amount, remaining))
+			return remaining
+		}
+	}
 
-		WithdrawFee: func(amount *big.Int) *big.Int {
-			// TODO: Adjust fee dynamically based on gas price?
-			fee := big.NewInt(2500000000000000) // 0.0025 ETH
-			return amount.Sub(amount, fee)
-		},
-		WithdrawMin: big.NewInt(5000000000000000), // 0.005 ETH
-		Settle:      settleHandler,
+	registerPayment := func(prefix, network string, networkBalanceStore store.BalanceStore, settle payment.SettleHandler) error {
+		svc := &payment.PaymentService{
+			NonceStore:   storeDriver,
+			AccountStore: storeDriver,
+			BalanceStore: networkBalanceStore,
+			WithdrawFee:  withdrawFee(network),
+			WithdrawMin:  withdrawMin,
+			Settle:       settle,
+		}
+		return handler.Register(prefix, svc)
 	}
-	if err := handler.Register("pool_", payment); err != nil {
-		return err
+
+	if router == nil {
+		if err := registerPayment("pool_", "", balanceStore, nil); err != nil {
+			return err
+		}
+	} else {
+		for i, nc := range router.Networks() {
+			settle, err := router.SettleFor(nc.Network)
+			if err != nil {
+				return err
+			}
+			settle = instrumentSettle(settle)
+			if i == 0 {
+				if err := registerPayment("pool_", nc.Network, nc.Contract, settle); err != nil {
+					return err
+				}
+			}
+			if err := registerPayment(fmt.Sprintf("pool_%s_", nc.Network), nc.Network, nc.Contract, settle); err != nil {
+				return err
+			}
+		}
+	}
+
+	if options.Pool.MetricsBind != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler)
+		go func() {
+			logger.Infof("Serving Prometheus metrics on: %s/metrics", options.Pool.MetricsBind)
+			if err := http.ListenAndServe(options.Pool.MetricsBind, metricsMux); err != nil {
+				logger.Errorf("Metrics server stopped: %s", err)
+			}
+		}()
+		go pollStoreSize(context.Background(), storeDriver)
 	}
 
 	if options.Pool.TLSHost != "" {
@@ -173,12 +413,3 @@ func runPool(options Options) error {
 	logger.Infof("Starting pool (version %s), listening on: %s", Version, options.Pool.Bind)
 	return http.ListenAndServe(options.Pool.Bind, handler)
 }
-
-func unlockTransactor(keystorePath string) (*bind.TransactOpts, error) {
-	pw := os.Getenv("KEYSTORE_PASSPHRASE")
-	r, err := os.Open(keystorePath)
-	if err != nil {
-		return nil, err
-	}
-	return bind.NewTransactor(r, pw)
-}
@@ -0,0 +1,102 @@
+// Package metrics exposes the pool's Prometheus instrumentation: a set of
+// shared collectors that other pool packages update as they do their work,
+// plus an http.Handler to mount behind --metrics-bind.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// ActiveHosts is the number of hosts currently active, by kind (e.g.
+	// "geth", "parity").
+	ActiveHosts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "vipnode_pool",
+		Name:      "active_hosts",
+		Help:      "Number of active hosts, by kind.",
+	}, []string{"kind"})
+
+	// BalanceCredited is the total balance credited to hosts by
+	// balance.PayPerInterval, in wei.
+	BalanceCredited = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vipnode_pool",
+		Name:      "balance_credited_wei_total",
+		Help:      "Total balance credited to hosts, in wei.",
+	})
+
+	// SettleAttempts counts every OpSettle call, successful or not.
+	SettleAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vipnode_pool",
+		Name:      "settle_attempts_total",
+		Help:      "Total number of settlement attempts.",
+	})
+
+	// SettleFailures counts OpSettle calls that returned an error.
+	SettleFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vipnode_pool",
+		Name:      "settle_failures_total",
+		Help:      "Total number of settlement attempts that failed.",
+	})
+
+	// SettleLatency is the latency of OpSettle calls. It does not cover
+	// JSON-RPC requests in general (the server/ws.Upgrader connection layer
+	// that would let us hook those isn't in this package's reach), only the
+	// settlement path instrumented by instrumentSettle.
+	SettleLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "vipnode_pool",
+		Name:      "settle_latency_seconds",
+		Help:      "Latency of settlement attempts, in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// StoreSize is the on-disk size of the persistent store, in bytes.
+	// runPool refreshes it periodically for stores that can report their
+	// size; it's left at 0 otherwise.
+	StoreSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "vipnode_pool",
+		Name:      "store_size_bytes",
+		Help:      "On-disk size of the persistent store, in bytes.",
+	})
+
+	// StatusCacheHits and StatusCacheMisses track PoolStatus's cache
+	// effectiveness; divide hits by (hits + misses) for a hit ratio.
+	StatusCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vipnode_pool",
+		Name:      "status_cache_hits_total",
+		Help:      "Number of Status calls served from cache.",
+	})
+	StatusCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vipnode_pool",
+		Name:      "status_cache_misses_total",
+		Help:      "Number of Status calls that triggered a cache refresh.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		ActiveHosts,
+		BalanceCredited,
+		SettleAttempts,
+		SettleFailures,
+		SettleLatency,
+		StoreSize,
+		StatusCacheHits,
+		StatusCacheMisses,
+	)
+}
+
+// Handler is the http.Handler to mount at /metrics.
+var Handler = promhttp.Handler()
+
+// ObserveSettleLatency is a convenience for timing an OpSettle call:
+//
+//	defer metrics.ObserveSettleLatency()()
+func ObserveSettleLatency() func() {
+	start := time.Now()
+	return func() {
+		SettleLatency.Observe(time.Since(start).Seconds())
+	}
+}
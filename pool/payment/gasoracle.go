@@ -0,0 +1,189 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// feeHistoryBlocks and feeHistoryPercentile control how GasOracle samples
+// EIP-1559 priority fees: the percentile tip paid over the trailing
+// feeHistoryBlocks blocks.
+const (
+	feeHistoryBlocks     = 10
+	feeHistoryPercentile = 60
+)
+
+// errNoFeeHistory is returned when a node reports support for
+// eth_feeHistory but the response has no usable reward/baseFee data (e.g.
+// a chain that hasn't activated EIP-1559 yet).
+var errNoFeeHistory = errors.New("payment: empty eth_feeHistory response")
+
+// GasOracle periodically samples the gas price from an ethclient connection
+// and caches the result so that WithdrawFee can be computed without blocking
+// on a network round-trip for every withdraw request.
+type GasOracle struct {
+	Client *ethclient.Client
+
+	// SampleInterval is how often the gas price is refreshed.
+	SampleInterval time.Duration
+
+	// GasEstimate is the amount of gas a settlement transaction is expected
+	// to consume.
+	GasEstimate uint64
+
+	// FeeMultiplier is applied on top of the sampled gas price to account
+	// for price movement between sampling and settlement.
+	FeeMultiplier float64
+
+	// Min and Max clamp the computed fee so that an oracle returning a
+	// spike or a zero can't be used to drain or lock up withdrawals.
+	Min *big.Int
+	Max *big.Int
+
+	mu       sync.RWMutex
+	gasPrice *big.Int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start takes a first sample synchronously, so that Fee has a value to
+// return immediately after Start returns, then begins the sampling
+// goroutine. If that first sample fails, Start returns its error without
+// retrying and no goroutine is started.
+func (o *GasOracle) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	o.cancel = cancel
+	o.done = make(chan struct{})
+
+	if err := o.sample(ctx); err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		defer close(o.done)
+		ticker := time.NewTicker(o.SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				o.sample(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop shuts down the sampling goroutine and waits for it to exit.
+func (o *GasOracle) Stop() {
+	if o.cancel == nil {
+		return
+	}
+	o.cancel()
+	<-o.done
+}
+
+func (o *GasOracle) sample(ctx context.Context) error {
+	sampleCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	gasPrice, err := o.sampleFeeHistory(sampleCtx)
+	if err != nil {
+		// Pre-London chains (or RPCs that don't support eth_feeHistory)
+		// fall back to the legacy gas price suggestion.
+		gasPrice, err = o.Client.SuggestGasPrice(sampleCtx)
+		if err != nil {
+			// Keep serving the last known good sample rather than failing
+			// the withdraw fee calculation outright.
+			return err
+		}
+	}
+
+	o.mu.Lock()
+	o.gasPrice = gasPrice
+	o.mu.Unlock()
+	return nil
+}
+
+// sampleFeeHistory estimates an effective gas price for EIP-1559 chains as
+// baseFee + the feeHistoryPercentile priority tip paid over the trailing
+// feeHistoryBlocks blocks.
+func (o *GasOracle) sampleFeeHistory(ctx context.Context) (*big.Int, error) {
+	history, err := o.Client.FeeHistory(ctx, feeHistoryBlocks, nil, []float64{feeHistoryPercentile})
+	if err != nil {
+		return nil, err
+	}
+	if len(history.BaseFee) == 0 || len(history.Reward) == 0 {
+		return nil, errNoFeeHistory
+	}
+
+	baseFee := history.BaseFee[len(history.BaseFee)-1]
+
+	tip := new(big.Int)
+	count := 0
+	for _, reward := range history.Reward {
+		if len(reward) == 0 {
+			continue
+		}
+		tip.Add(tip, reward[0])
+		count++
+	}
+	if count == 0 {
+		return nil, errNoFeeHistory
+	}
+	tip.Div(tip, big.NewInt(int64(count)))
+
+	return new(big.Int).Add(baseFee, tip), nil
+}
+
+// Fee computes the withdraw fee based on the most recently sampled gas
+// price, clamped to [Min, Max], and returns amount minus that fee (0 if the
+// fee exceeds amount), not the fee itself. Max is never nil in
+// practice: callers that don't configure one get defaultWithdrawFeeCeiling,
+// since an unbounded fee defeats the whole point of the ceiling.
+func (o *GasOracle) Fee(amount *big.Int) *big.Int {
+	o.mu.RLock()
+	gasPrice := o.gasPrice
+	o.mu.RUnlock()
+
+	fee := new(big.Int)
+	if gasPrice != nil {
+		fee.Mul(gasPrice, new(big.Int).SetUint64(o.GasEstimate))
+		fee = mulFloat(fee, o.FeeMultiplier)
+	}
+
+	if o.Min != nil && fee.Cmp(o.Min) < 0 {
+		fee = o.Min
+	}
+	max := o.Max
+	if max == nil {
+		max = defaultWithdrawFeeCeiling
+	}
+	if fee.Cmp(max) > 0 {
+		fee = max
+	}
+
+	remaining := new(big.Int).Sub(amount, fee)
+	if remaining.Sign() < 0 {
+		return big.NewInt(0)
+	}
+	return remaining
+}
+
+// defaultWithdrawFeeCeiling caps the withdraw fee when no --withdraw-fee-ceiling
+// was configured, so a misconfigured pool still can't charge an unbounded fee.
+var defaultWithdrawFeeCeiling = big.NewInt(10000000000000000) // 0.01 ETH
+
+func mulFloat(v *big.Int, f float64) *big.Int {
+	scaled := new(big.Float).Mul(new(big.Float).SetInt(v), big.NewFloat(f))
+	result, _ := scaled.Int(nil)
+	return result
+}
@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestGasOracleFeeClamping(t *testing.T) {
+	tests := []struct {
+		name     string
+		gasPrice *big.Int // nil means no sample has ever succeeded
+		o        GasOracle
+		amount   *big.Int
+		want     *big.Int
+	}{
+		{
+			name:     "floor raises a tiny fee",
+			gasPrice: big.NewInt(1),
+			o: GasOracle{
+				GasEstimate:   1,
+				FeeMultiplier: 1,
+				Min:           big.NewInt(1000),
+				Max:           big.NewInt(1000000),
+			},
+			amount: big.NewInt(1000000),
+			want:   big.NewInt(1000000 - 1000),
+		},
+		{
+			name:     "ceiling caps a gas price spike",
+			gasPrice: big.NewInt(1000000000000),
+			o: GasOracle{
+				GasEstimate:   21000,
+				FeeMultiplier: 1,
+				Min:           big.NewInt(1000),
+				Max:           big.NewInt(1000000),
+			},
+			amount: big.NewInt(2000000),
+			want:   big.NewInt(2000000 - 1000000),
+		},
+		{
+			name:     "no sample yet falls back to the floor",
+			gasPrice: nil,
+			o: GasOracle{
+				GasEstimate:   21000,
+				FeeMultiplier: 1,
+				Min:           big.NewInt(1000),
+				Max:           big.NewInt(1000000),
+			},
+			amount: big.NewInt(1000000),
+			want:   big.NewInt(1000000 - 1000),
+		},
+		{
+			name:     "fee larger than amount clamps to zero instead of negative",
+			gasPrice: big.NewInt(1000000000000),
+			o: GasOracle{
+				GasEstimate:   21000,
+				FeeMultiplier: 1,
+				Min:           big.NewInt(1000),
+				Max:           big.NewInt(1000000),
+			},
+			amount: big.NewInt(500),
+			want:   big.NewInt(0),
+		},
+		{
+			name:     "nil Max falls back to the default ceiling",
+			gasPrice: new(big.Int).Mul(defaultWithdrawFeeCeiling, big.NewInt(100)),
+			o: GasOracle{
+				GasEstimate:   1,
+				FeeMultiplier: 1,
+				Min:           big.NewInt(1000),
+			},
+			amount: new(big.Int).Add(defaultWithdrawFeeCeiling, big.NewInt(1)),
+			want:   big.NewInt(1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.o
+			o.gasPrice = tt.gasPrice
+			got := o.Fee(tt.amount)
+			if got.Cmp(tt.want) != 0 {
+				t.Fatalf("Fee(%s) = %s, want %s", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/vipnode/vipnode/ethnode"
+)
+
+// NetworkContract pairs a deployed payment contract with the network it's
+// deployed on.
+type NetworkContract struct {
+	Network  string
+	Contract *ContractPayment
+}
+
+// NetworkRouter fans out balance and settlement operations across multiple
+// payment contracts, one per Ethereum network, so a single pool can serve
+// hosts and clients across several networks (e.g. mainnet and a testnet)
+// without running separate processes.
+//
+// Addresses are routed by network using the "network:0xaddress" convention
+// produced by NetworkAddress; callers that already know the client's
+// declared network (the RPC layer that receives the vipnode handshake)
+// are responsible for qualifying addresses before they reach the router.
+type NetworkRouter struct {
+	networks  []NetworkContract
+	contracts map[string]*ContractPayment
+}
+
+// NewNetworkRouter builds a router from a set of per-network contracts.
+// It returns an error if any two networks resolved to the same contract
+// address, since that's almost certainly a misconfiguration.
+func NewNetworkRouter(contracts []NetworkContract) (*NetworkRouter, error) {
+	r := &NetworkRouter{
+		networks:  contracts,
+		contracts: make(map[string]*ContractPayment, len(contracts)),
+	}
+	seenAddr := map[string]string{}
+	for _, nc := range contracts {
+		addr := nc.Contract.Address().Hex()
+		if network, ok := seenAddr[addr]; ok {
+			return nil, fmt.Errorf("payment contract %s is configured for both %q and %q, contract addresses must not overlap across networks", addr, network, nc.Network)
+		}
+		seenAddr[addr] = nc.Network
+		r.contracts[nc.Network] = nc.Contract
+	}
+	return r, nil
+}
+
+// Networks returns the configured networks in the order they were provided.
+func (r *NetworkRouter) Networks() []NetworkContract {
+	return r.networks
+}
+
+// NetworkAddress qualifies an address with its network, for use as a key
+// into the router's underlying stores.
+func NetworkAddress(network, address string) string {
+	return network + ":" + address
+}
+
+// splitNetworkAddress reverses NetworkAddress.
+func splitNetworkAddress(qualified string) (network, address string, err error) {
+	for i := 0; i < len(qualified); i++ {
+		if qualified[i] == ':' {
+			return qualified[:i], qualified[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("address %q is not network-qualified, expected \"network:0xaddress\"", qualified)
+}
+
+// ContractFor returns the contract registered for network, if any.
+func (r *NetworkRouter) ContractFor(network string) (*ContractPayment, bool) {
+	c, ok := r.contracts[network]
+	return c, ok
+}
+
+// Balance implements store.BalanceStore by routing to the contract for the
+// address's network.
+func (r *NetworkRouter) Balance(qualifiedAddress string) (*big.Int, error) {
+	network, address, err := splitNetworkAddress(qualifiedAddress)
+	if err != nil {
+		return nil, err
+	}
+	contract, ok := r.ContractFor(network)
+	if !ok {
+		return nil, fmt.Errorf("no payment contract configured for network %q", network)
+	}
+	return contract.Balance(address)
+}
+
+// AddBalance implements store.BalanceStore by routing to the contract for
+// the address's network.
+func (r *NetworkRouter) AddBalance(qualifiedAddress string, amount *big.Int) (*big.Int, error) {
+	network, address, err := splitNetworkAddress(qualifiedAddress)
+	if err != nil {
+		return nil, err
+	}
+	contract, ok := r.ContractFor(network)
+	if !ok {
+		return nil, fmt.Errorf("no payment contract configured for network %q", network)
+	}
+	return contract.AddBalance(address, amount)
+}
+
+// SettleFor returns a SettleHandler bound to the contract for network, or an
+// error if no contract is configured for it. This is what lets the pool
+// dispatch a settlement request to the right chain once it knows the
+// client's declared network.
+func (r *NetworkRouter) SettleFor(network string) (SettleHandler, error) {
+	contract, ok := r.ContractFor(network)
+	if !ok {
+		return nil, fmt.Errorf("no payment contract configured for network %q", network)
+	}
+	return contract.OpSettle, nil
+}
+
+// CheckNetworkID confirms that an ethclient connection is actually on the
+// network it was configured for, so a typo'd --pool-contract-rpc can't
+// silently bind the wrong chain to a network key.
+func CheckNetworkID(client *ethclient.Client, network string) error {
+	gotNetwork, err := client.NetworkID(context.Background())
+	if err != nil {
+		return err
+	}
+	if networkID := ethnode.NetworkID(int(gotNetwork.Int64())); !networkID.Is(network) {
+		return fmt.Errorf("ethereum network mismatch: contract RPC is a %q node, expected %q", networkID, network)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package payment
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestNetworkAddressRoundTrip(t *testing.T) {
+	qualified := NetworkAddress("mainnet", "0xabc")
+	network, address, err := splitNetworkAddress(qualified)
+	if err != nil {
+		t.Fatalf("splitNetworkAddress(%q) error = %v", qualified, err)
+	}
+	if network != "mainnet" || address != "0xabc" {
+		t.Fatalf("splitNetworkAddress(%q) = (%q, %q), want (\"mainnet\", \"0xabc\")", qualified, network, address)
+	}
+}
+
+func TestNetworkRouterRejectsUnqualifiedAddress(t *testing.T) {
+	r, err := NewNetworkRouter(nil)
+	if err != nil {
+		t.Fatalf("NewNetworkRouter(nil) error = %v", err)
+	}
+
+	if _, err := r.Balance("0xabc"); err == nil || !strings.Contains(err.Error(), "not network-qualified") {
+		t.Fatalf("Balance(\"0xabc\") error = %v, want a \"not network-qualified\" error", err)
+	}
+	if _, err := r.AddBalance("0xabc", big.NewInt(1)); err == nil || !strings.Contains(err.Error(), "not network-qualified") {
+		t.Fatalf("AddBalance(\"0xabc\", ...) error = %v, want a \"not network-qualified\" error", err)
+	}
+}
+
+func TestNetworkRouterRejectsUnknownNetwork(t *testing.T) {
+	r, err := NewNetworkRouter(nil)
+	if err != nil {
+		t.Fatalf("NewNetworkRouter(nil) error = %v", err)
+	}
+
+	qualified := NetworkAddress("mainnet", "0xabc")
+	if _, err := r.Balance(qualified); err == nil || !strings.Contains(err.Error(), "no payment contract configured") {
+		t.Fatalf("Balance(%q) error = %v, want a \"no payment contract configured\" error", qualified, err)
+	}
+	if _, err := r.AddBalance(qualified, big.NewInt(1)); err == nil || !strings.Contains(err.Error(), "no payment contract configured") {
+		t.Fatalf("AddBalance(%q, ...) error = %v, want a \"no payment contract configured\" error", qualified, err)
+	}
+}
@@ -2,13 +2,127 @@ package status
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/vipnode/vipnode/jsonrpc2"
+	"github.com/vipnode/vipnode/pool/metrics"
 	"github.com/vipnode/vipnode/pool/store"
 )
 
-// TODO: Support event sub?
+// hostQueryTimeout bounds how long getStatus will wait on any single host's
+// eth_blockNumber/net_peerCount/web3_clientVersion calls, so a single
+// misbehaving host can't stall the whole status refresh.
+const hostQueryTimeout = 2 * time.Second
+
+// HostDialer reconnects to an active host over its existing RPC connection
+// so PoolStatus can collect live chain data for the dashboard. It's
+// satisfied by the pool's node connection manager.
+type HostDialer interface {
+	DialHost(ctx context.Context, nodeID store.NodeID) (HostRPC, error)
+}
+
+// HostRPC is the subset of a host's JSON-RPC surface that PoolStatus needs
+// to enrich a Host view.
+type HostRPC interface {
+	BlockNumber(ctx context.Context) (uint64, string, error)
+	PeerCount(ctx context.Context) (int, error)
+	NetworkID(ctx context.Context) (string, error)
+	ClientVersion(ctx context.Context) (string, error)
+}
+
+// HostRegistry is a concrete HostDialer: whatever manages a host's live RPC
+// connection (the pool's node connection manager) calls Register when the
+// host connects and Unregister when it disconnects, and PoolStatus looks
+// the connection back up by node ID when it needs to collect live data.
+type HostRegistry struct {
+	mu    sync.RWMutex
+	hosts map[store.NodeID]HostRPC
+}
+
+// Register associates nodeID with an already-connected HostRPC, replacing
+// any previous one for the same node ID.
+func (reg *HostRegistry) Register(nodeID store.NodeID, rpc HostRPC) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.hosts == nil {
+		reg.hosts = map[store.NodeID]HostRPC{}
+	}
+	reg.hosts[nodeID] = rpc
+}
+
+// Unregister removes nodeID, e.g. once the host disconnects. It's safe to
+// call even if nodeID was never registered.
+func (reg *HostRegistry) Unregister(nodeID store.NodeID) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.hosts, nodeID)
+}
+
+// DialHost implements HostDialer by looking up the registered connection
+// for nodeID. ctx is unused since the connection is already established;
+// it's part of the signature so other HostDialer implementations can
+// dial out lazily instead.
+func (reg *HostRegistry) DialHost(ctx context.Context, nodeID store.NodeID) (HostRPC, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	rpc, ok := reg.hosts[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("status: no live connection registered for node %q", nodeID)
+	}
+	return rpc, nil
+}
+
+// collectHostInfo fills in the live fields of h by querying the host over
+// dialer, bounded by hostQueryTimeout. Errors are swallowed (the fields are
+// simply left zero-valued) since a single stale host shouldn't fail the
+// whole status refresh.
+func collectHostInfo(ctx context.Context, dialer HostDialer, nodeID store.NodeID, h *Host) {
+	if dialer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, hostQueryTimeout)
+	defer cancel()
+
+	rpc, err := dialer.DialHost(ctx, nodeID)
+	if err != nil {
+		return
+	}
+
+	if block, hash, err := rpc.BlockNumber(ctx); err == nil {
+		h.LatestBlock = block
+		h.LatestBlockHash = hash
+	}
+	if peers, err := rpc.PeerCount(ctx); err == nil {
+		h.PeerCount = peers
+	}
+	if network, err := rpc.NetworkID(ctx); err == nil {
+		h.NetworkID = network
+	}
+	if version, err := rpc.ClientVersion(ctx); err == nil {
+		h.ClientVersion = version
+	}
+}
+
+// scoreHosts sets Score on each host to its latest block height relative to
+// the median height across all of them.
+func scoreHosts(hosts []Host) {
+	if len(hosts) == 0 {
+		return
+	}
+	blocks := make([]uint64, len(hosts))
+	for i, h := range hosts {
+		blocks[i] = h.LatestBlock
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+	median := int64(blocks[len(blocks)/2])
+
+	for i := range hosts {
+		hosts[i].Score = int64(hosts[i].LatestBlock) - median
+	}
+}
 
 // Host is a public view of a hosting node.
 type Host struct {
@@ -16,8 +130,20 @@ type Host struct {
 	LastSeen time.Time `json:"last_seen"`
 	Kind     string    `json:"kind"`
 
-	// TODO: Add latest block
-	// TODO: Add peers
+	// LatestBlock, LatestBlockHash, PeerCount, NetworkID, and ClientVersion
+	// are collected live from the host on the same cadence as the status
+	// cache. They're left zero-valued if the host couldn't be reached
+	// within hostQueryTimeout.
+	LatestBlock     uint64 `json:"latest_block"`
+	LatestBlockHash string `json:"latest_block_hash"`
+	PeerCount       int    `json:"peer_count"`
+	NetworkID       string `json:"network_id"`
+	ClientVersion   string `json:"client_version"`
+
+	// Score is the host's latest block height relative to the median
+	// height across all active hosts, in blocks. 0 means in sync with the
+	// network; a large negative value flags a stale or forked host.
+	Score int64 `json:"score"`
 }
 
 func nodeHost(n store.Node) Host {
@@ -52,12 +178,56 @@ type StatusResponse struct {
 	Error error `json:"error,omitempty"`
 }
 
+// activeHostSet returns a set of the short IDs present in a StatusResponse,
+// used to detect whether the set of active hosts changed between cache
+// refreshes.
+func activeHostSet(r *StatusResponse) map[string]bool {
+	set := make(map[string]bool, len(r.ActiveHosts))
+	for _, h := range r.ActiveHosts {
+		set[h.ShortID] = true
+	}
+	return set
+}
+
+// hostSetChanged returns true if the two responses have a different set of
+// active hosts (a join or leave happened).
+func hostSetChanged(a, b *StatusResponse) bool {
+	if a == nil || b == nil {
+		return a != b
+	}
+	setA, setB := activeHostSet(a), activeHostSet(b)
+	if len(setA) != len(setB) {
+		return true
+	}
+	for id := range setA {
+		if !setB[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriberBacklog is the number of pending notifications a subscriber can
+// accumulate before it's considered slow and dropped.
+const subscriberBacklog = 8
+
+// subscriber is a single registered push target for status updates.
+type subscriber struct {
+	ch chan *StatusResponse
+}
+
 // PoolStatus is a service for providing data to a pool status dashboard over
 // RPC. Because status calls are unathenticated, the service only provides
 // cached public consumable data.
 type PoolStatus struct {
 	Store store.Store
 
+	// Dialer is used to collect live chain data (latest block, peers,
+	// network, client version) from active hosts. If nil, Host entries are
+	// only populated with the cached store data. In practice this should be
+	// a *HostRegistry kept in sync with the pool's live host connections.
+	Dialer HostDialer
+
 	// TimeStarted is the time when the server was started.
 	TimeStarted time.Time
 
@@ -69,6 +239,89 @@ type PoolStatus struct {
 
 	mu         sync.RWMutex
 	cachedResp *StatusResponse
+
+	subMu   sync.Mutex
+	subs    map[string]*subscriber
+	nextSub uint64
+}
+
+// Register adds a subscriber that will receive a copy of every
+// StatusResponse broadcast from Status when the active host set changes. It
+// returns a subscription id that must be passed to Unregister once the
+// subscriber disconnects.
+func (s *PoolStatus) Register() (id string, ch <-chan *StatusResponse) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	if s.subs == nil {
+		s.subs = map[string]*subscriber{}
+	}
+	s.nextSub++
+	id = fmt.Sprintf("%d", s.nextSub)
+	sub := &subscriber{ch: make(chan *StatusResponse, subscriberBacklog)}
+	s.subs[id] = sub
+	return id, sub.ch
+}
+
+// Unregister removes a subscriber. It's safe to call more than once.
+func (s *PoolStatus) Unregister(id string) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	if sub, ok := s.subs[id]; ok {
+		close(sub.ch)
+		delete(s.subs, id)
+	}
+}
+
+// broadcast pushes r to every registered subscriber. A subscriber whose
+// channel is full is considered too slow to keep up and is dropped rather
+// than blocking the rest of the pool on it.
+func (s *PoolStatus) broadcast(r *StatusResponse) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	for id, sub := range s.subs {
+		select {
+		case sub.ch <- r:
+		default:
+			close(sub.ch)
+			delete(s.subs, id)
+		}
+	}
+}
+
+// Subscribe registers the caller for push-based status updates, delivered
+// whenever the set of active hosts changes. It's registered on the pool_
+// namespace so dashboards can replace long-polling Status calls with a live
+// feed over the existing jsonrpc2/ws transport.
+func (s *PoolStatus) Subscribe(ctx context.Context) (*jsonrpc2.Subscription, error) {
+	notifier, ok := jsonrpc2.NotifierFromContext(ctx)
+	if !ok {
+		return nil, jsonrpc2.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	subID, ch := s.Register()
+
+	go func() {
+		defer s.Unregister(subID)
+		for {
+			select {
+			case r, ok := <-ch:
+				if !ok {
+					return
+				}
+				if err := notifier.Notify(rpcSub.ID, r); err != nil {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
 }
 
 // getStatus is an uncached version of Status
@@ -85,9 +338,25 @@ func (s *PoolStatus) getStatus() (*StatusResponse, error) {
 		return r, err
 	}
 
-	r.ActiveHosts = make([]Host, 0, len(nodes))
-	for _, n := range nodes {
-		r.ActiveHosts = append(r.ActiveHosts, nodeHost(n))
+	r.ActiveHosts = make([]Host, len(nodes))
+	var wg sync.WaitGroup
+	byKind := map[string]int{}
+	for i, n := range nodes {
+		r.ActiveHosts[i] = nodeHost(n)
+		byKind[n.Kind]++
+		wg.Add(1)
+		go func(i int, nodeID store.NodeID) {
+			defer wg.Done()
+			collectHostInfo(context.Background(), s.Dialer, nodeID, &r.ActiveHosts[i])
+		}(i, n.ID)
+	}
+	wg.Wait()
+	scoreHosts(r.ActiveHosts)
+
+	// Reset first so kinds with no active hosts left drop out of the gauge.
+	metrics.ActiveHosts.Reset()
+	for kind, count := range byKind {
+		metrics.ActiveHosts.WithLabelValues(kind).Set(float64(count))
 	}
 
 	return r, nil
@@ -101,6 +370,7 @@ func (s *PoolStatus) Status(ctx context.Context) (*StatusResponse, error) {
 
 	if cachedResp != nil && cachedResp.TimeUpdated.Add(s.CacheDuration).After(time.Now()) {
 		// Cache is valid
+		metrics.StatusCacheHits.Inc()
 		return cachedResp, nil
 	}
 
@@ -109,11 +379,17 @@ func (s *PoolStatus) Status(ctx context.Context) (*StatusResponse, error) {
 
 	// Did another request beat us to it?
 	if s.cachedResp != cachedResp {
+		metrics.StatusCacheHits.Inc()
 		return s.cachedResp, nil
 	}
 
+	metrics.StatusCacheMisses.Inc()
+
 	// We save the status even if there is an error (to avoid an error-based DoS)
 	r, err := s.getStatus()
+	if hostSetChanged(cachedResp, r) {
+		s.broadcast(r)
+	}
 	s.cachedResp = r
 	return r, err
-}
\ No newline at end of file
+}
@@ -0,0 +1,154 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// fakeStore stubs store.Store, implementing only the methods PoolStatus
+// actually calls. The embedded nil store.Store will panic if anything else
+// is called, which is intentional: it would mean this test needs updating.
+type fakeStore struct {
+	store.Store
+	hosts []store.Node
+}
+
+func (f *fakeStore) ActiveHosts(kind string, limit int) ([]store.Node, error) {
+	return f.hosts, nil
+}
+
+// Subscribe itself requires a jsonrpc2.Notifier pulled off the request
+// context by the ws transport, so these tests exercise the subscription hub
+// (Register/Unregister/broadcast, and the hostSetChanged gate that decides
+// when Status broadcasts) directly rather than through Subscribe.
+
+func TestBroadcastOnHostSetChange(t *testing.T) {
+	fake := &fakeStore{}
+	s := &PoolStatus{
+		Store:         fake,
+		CacheDuration: time.Millisecond,
+	}
+
+	id, ch := s.Register()
+	defer s.Unregister(id)
+
+	// A host joins.
+	fake.hosts = []store.Node{{ID: "host-one", Kind: "geth"}}
+	time.Sleep(time.Millisecond)
+	if _, err := s.Status(context.Background()); err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	select {
+	case r := <-ch:
+		if len(r.ActiveHosts) != 1 || r.ActiveHosts[0].ShortID != "host-one" {
+			t.Fatalf("unexpected join notification: %+v", r.ActiveHosts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for join notification")
+	}
+
+	// The host leaves.
+	fake.hosts = nil
+	time.Sleep(time.Millisecond)
+	if _, err := s.Status(context.Background()); err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	select {
+	case r := <-ch:
+		if len(r.ActiveHosts) != 0 {
+			t.Fatalf("unexpected leave notification: %+v", r.ActiveHosts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for leave notification")
+	}
+}
+
+func TestNoBroadcastWhenHostSetUnchanged(t *testing.T) {
+	fake := &fakeStore{hosts: []store.Node{{ID: "host-one", Kind: "geth"}}}
+	s := &PoolStatus{
+		Store:         fake,
+		CacheDuration: time.Millisecond,
+	}
+
+	id, ch := s.Register()
+	defer s.Unregister(id)
+
+	if _, err := s.Status(context.Background()); err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	<-ch // first refresh always broadcasts (nil -> non-nil)
+
+	time.Sleep(time.Millisecond)
+	if _, err := s.Status(context.Background()); err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	select {
+	case r := <-ch:
+		t.Fatalf("unexpected broadcast with unchanged host set: %+v", r.ActiveHosts)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+type fakeHostRPC struct {
+	block uint64
+}
+
+func (f *fakeHostRPC) BlockNumber(ctx context.Context) (uint64, string, error) {
+	return f.block, "0xhash", nil
+}
+func (f *fakeHostRPC) PeerCount(ctx context.Context) (int, error)    { return 3, nil }
+func (f *fakeHostRPC) NetworkID(ctx context.Context) (string, error) { return "1", nil }
+func (f *fakeHostRPC) ClientVersion(ctx context.Context) (string, error) {
+	return "geth/test", nil
+}
+
+func TestHostRegistryCollectsLiveData(t *testing.T) {
+	reg := &HostRegistry{}
+	reg.Register("host-one", &fakeHostRPC{block: 42})
+
+	fake := &fakeStore{hosts: []store.Node{{ID: "host-one", Kind: "geth"}}}
+	s := &PoolStatus{
+		Store:         fake,
+		Dialer:        reg,
+		CacheDuration: time.Minute,
+	}
+
+	r, err := s.getStatus()
+	if err != nil {
+		t.Fatalf("getStatus() error = %v", err)
+	}
+	if len(r.ActiveHosts) != 1 || r.ActiveHosts[0].LatestBlock != 42 || r.ActiveHosts[0].PeerCount != 3 {
+		t.Fatalf("getStatus() host = %+v, want a live-collected host", r.ActiveHosts)
+	}
+
+	reg.Unregister("host-one")
+	r, err = s.getStatus()
+	if err != nil {
+		t.Fatalf("getStatus() error = %v", err)
+	}
+	if len(r.ActiveHosts) != 1 || r.ActiveHosts[0].LatestBlock != 0 {
+		t.Fatalf("getStatus() host = %+v, want zero-valued live fields after Unregister", r.ActiveHosts)
+	}
+}
+
+func TestUnregisterStopsDelivery(t *testing.T) {
+	s := &PoolStatus{}
+
+	id, ch := s.Register()
+	s.Unregister(id)
+
+	select {
+	case r, ok := <-ch:
+		if ok {
+			t.Fatalf("expected closed channel after Unregister, got %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for feed to close")
+	}
+}
@@ -0,0 +1,208 @@
+// Package postgres implements a store.Store backed by PostgreSQL, so a pool
+// can run horizontally behind a load balancer with balance state shared
+// across replicas instead of pinned to a single process's badger database.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"time"
+
+	// Registers the "postgres" database/sql driver.
+	_ "github.com/lib/pq"
+
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// defaultHostExpiry is how long a node is considered active since its last
+// UpdateNode call, if Store.Expiry is left unset.
+const defaultHostExpiry = 1 * time.Hour
+
+// Open connects to dsn, bootstraps the schema if needed, and returns a
+// store.Store backed by the resulting connection pool. The caller is
+// responsible for calling Close when done.
+func Open(dsn string) (store.Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db, Expiry: defaultHostExpiry}, nil
+}
+
+// Store implements store.Store, store.BalanceStore, store.NonceStore, and
+// store.AccountStore on top of a shared postgres database. Balance mutations
+// run inside a transaction so concurrent replicas can't race each other's
+// reads and writes.
+type Store struct {
+	db *sql.DB
+
+	// Expiry is how long ago a node can have last been seen and still be
+	// considered active by ActiveHosts. Open sets this to defaultHostExpiry;
+	// callers are free to override it before first use.
+	Expiry time.Duration
+}
+
+// Close releases the underlying connection pool.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Size returns the on-disk size of the database, in bytes, for callers that
+// want to expose it (e.g. as a metrics.StoreSize gauge).
+func (s *Store) Size(ctx context.Context) (int64, error) {
+	var size int64
+	err := s.db.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&size)
+	return size, err
+}
+
+// ActiveHosts returns nodes of the given kind (or all kinds, if empty) seen
+// within the last s.Expiry (defaultHostExpiry, unless overridden), most
+// recently seen first, limited to limit results (or unlimited, if 0).
+func (s *Store) ActiveHosts(kind string, limit int) ([]store.Node, error) {
+	expiry := s.Expiry
+	if expiry == 0 {
+		expiry = defaultHostExpiry
+	}
+
+	query := `SELECT id, kind, last_seen FROM nodes WHERE last_seen > $1`
+	args := []interface{}{time.Now().Add(-expiry)}
+	if kind != "" {
+		query += ` AND kind = $2`
+		args = append(args, kind)
+	}
+	query += ` ORDER BY last_seen DESC`
+	if limit > 0 {
+		query += fmt.Sprintf(` LIMIT $%d`, len(args)+1)
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []store.Node
+	for rows.Next() {
+		var n store.Node
+		var id string
+		if err := rows.Scan(&id, &n.Kind, &n.LastSeen); err != nil {
+			return nil, err
+		}
+		n.ID = store.NodeID(id)
+		nodes = append(nodes, n)
+	}
+	return nodes, rows.Err()
+}
+
+// UpdateNode upserts a node's last-seen timestamp and kind.
+func (s *Store) UpdateNode(n store.Node) error {
+	_, err := s.db.Exec(`
+		INSERT INTO nodes (id, kind, last_seen)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET kind = $2, last_seen = $3
+	`, string(n.ID), n.Kind, n.LastSeen)
+	return err
+}
+
+// Balance returns the current credited balance for address.
+func (s *Store) Balance(address string) (*big.Int, error) {
+	var raw string
+	err := s.db.QueryRow(`SELECT balance FROM accounts WHERE address = $1`, address).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return nil, store.ErrMalformedStore
+	}
+	return balance, nil
+}
+
+// AddBalance atomically adds amount (which may be negative) to address's
+// balance and returns the resulting total.
+func (s *Store) AddBalance(address string, amount *big.Int) (*big.Int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO accounts (address, balance)
+		VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET balance = accounts.balance + $2
+	`, address, amount.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var raw string
+	if err := tx.QueryRow(`SELECT balance FROM accounts WHERE address = $1`, address).Scan(&raw); err != nil {
+		return nil, err
+	}
+	balance, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return nil, store.ErrMalformedStore
+	}
+
+	return balance, tx.Commit()
+}
+
+// GetNonce returns the last nonce saved for address, or 0 if none has been
+// saved yet.
+func (s *Store) GetNonce(address string) (uint64, error) {
+	var nonce uint64
+	err := s.db.QueryRow(`SELECT nonce FROM nonces WHERE address = $1`, address).Scan(&nonce)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return nonce, err
+}
+
+// CheckAndSaveNonce atomically checks that nonce is greater than the stored
+// value, then saves it, returning an error if the check fails so replays
+// can't be accepted twice across replicas.
+func (s *Store) CheckAndSaveNonce(address string, nonce uint64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var last uint64
+	err = tx.QueryRow(`SELECT nonce FROM nonces WHERE address = $1 FOR UPDATE`, address).Scan(&last)
+	hasPrior := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	// A fresh address has no row yet (GetNonce reports 0 for it), so a first
+	// nonce of 0 must be accepted here too; comparing against the zero value
+	// of last would otherwise reject the legitimate first nonce.
+	if hasPrior && nonce <= last {
+		return store.ErrInvalidNonce
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO nonces (address, nonce)
+		VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET nonce = $2
+	`, address, nonce)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
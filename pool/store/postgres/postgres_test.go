@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// nodeToStore builds the store.Node UpdateNode expects for a soak iteration;
+// round only affects LastSeen so repeated calls still look like real churn.
+func nodeToStore(nodeID string, round int) store.Node {
+	return store.Node{
+		ID:       store.NodeID(nodeID),
+		Kind:     "geth",
+		LastSeen: time.Now().Add(time.Duration(round) * time.Millisecond),
+	}
+}
+
+// openTestStore connects to the database named by VIPNODE_POSTGRES_TEST_DSN,
+// skipping the test if it isn't set. This package has no way to start its
+// own postgres instance, so the soak test below only runs where an operator
+// (or CI) has pointed it at a real, disposable database.
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("VIPNODE_POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("VIPNODE_POSTGRES_TEST_DSN not set, skipping postgres store test")
+	}
+	s, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("Open(%q) error = %v", dsn, err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s.(*Store)
+}
+
+// TestSoak exercises store.Store/BalanceStore/NonceStore operations
+// concurrently across many goroutines, to catch races that only a real
+// transactional backend can hit (e.g. two replicas crediting the same host
+// at once). It covers the Store/BalanceStore/NonceStore surface by hand; it
+// does not run a conformance suite shared with the badger store.
+func TestSoak(t *testing.T) {
+	s := openTestStore(t)
+
+	const hosts = 20
+	const roundsPerHost = 25
+
+	var wg sync.WaitGroup
+	for i := 0; i < hosts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nodeID := fmt.Sprintf("soak-node-%d", i)
+			address := fmt.Sprintf("soak-address-%d", i)
+
+			for round := 0; round < roundsPerHost; round++ {
+				if err := s.UpdateNode(nodeToStore(nodeID, round)); err != nil {
+					t.Errorf("UpdateNode(%s) error = %v", nodeID, err)
+					return
+				}
+				if _, err := s.AddBalance(address, big.NewInt(1)); err != nil {
+					t.Errorf("AddBalance(%s) error = %v", address, err)
+					return
+				}
+				if err := s.CheckAndSaveNonce(address, uint64(round)); err != nil {
+					t.Errorf("CheckAndSaveNonce(%s, %d) error = %v", address, round, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < hosts; i++ {
+		address := fmt.Sprintf("soak-address-%d", i)
+		balance, err := s.Balance(address)
+		if err != nil {
+			t.Fatalf("Balance(%s) error = %v", address, err)
+		}
+		if balance.Cmp(big.NewInt(roundsPerHost)) != 0 {
+			t.Errorf("Balance(%s) = %s, want %d", address, balance, roundsPerHost)
+		}
+	}
+
+	active, err := s.ActiveHosts("", 0)
+	if err != nil {
+		t.Fatalf("ActiveHosts() error = %v", err)
+	}
+	if len(active) < hosts {
+		t.Errorf("ActiveHosts() returned %d nodes, want at least %d", len(active), hosts)
+	}
+}
+
+// TestSize confirms Size returns a positive, real database size rather than
+// a stub value.
+func TestSize(t *testing.T) {
+	s := openTestStore(t)
+
+	size, err := s.Size(context.Background())
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+	if size <= 0 {
+		t.Fatalf("Size() = %d, want > 0", size)
+	}
+}
+
+// TestCheckAndSaveNonceAcceptsFirstZero confirms a fresh address's first
+// nonce of 0 is accepted, matching the 0 GetNonce reports for an address
+// with no prior nonce saved.
+func TestCheckAndSaveNonceAcceptsFirstZero(t *testing.T) {
+	s := openTestStore(t)
+	address := "nonce-zero-address"
+
+	if err := s.CheckAndSaveNonce(address, 0); err != nil {
+		t.Fatalf("CheckAndSaveNonce(%s, 0) error = %v, want nil", address, err)
+	}
+	if err := s.CheckAndSaveNonce(address, 0); err != store.ErrInvalidNonce {
+		t.Fatalf("CheckAndSaveNonce(%s, 0) replay error = %v, want ErrInvalidNonce", address, err)
+	}
+}
+
+// TestActiveHostsExpiry confirms ActiveHosts honors s.Expiry rather than a
+// hardcoded window.
+func TestActiveHostsExpiry(t *testing.T) {
+	s := openTestStore(t)
+	s.Expiry = time.Millisecond
+
+	nodeID := "expiry-node"
+	if err := s.UpdateNode(nodeToStore(nodeID, 0)); err != nil {
+		t.Fatalf("UpdateNode() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	active, err := s.ActiveHosts("", 0)
+	if err != nil {
+		t.Fatalf("ActiveHosts() error = %v", err)
+	}
+	for _, n := range active {
+		if string(n.ID) == nodeID {
+			t.Fatalf("ActiveHosts() returned %q, expected it to have expired under a %s window", nodeID, s.Expiry)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package postgres
+
+// schema is bootstrapped on Open if the tables don't already exist, so
+// operators don't need a separate migration step to stand up a pool.
+// Later schema changes should be appended as additional idempotent
+// statements rather than rewriting these.
+const schema = `
+CREATE TABLE IF NOT EXISTS nodes (
+	id TEXT PRIMARY KEY,
+	kind TEXT NOT NULL DEFAULT '',
+	last_seen TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS nodes_kind_last_seen_idx ON nodes (kind, last_seen DESC);
+
+CREATE TABLE IF NOT EXISTS accounts (
+	address TEXT PRIMARY KEY,
+	balance NUMERIC(78, 0) NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS nonces (
+	address TEXT PRIMARY KEY,
+	nonce BIGINT NOT NULL DEFAULT 0
+);
+`
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// resolveTransactor builds a *bind.TransactOpts for the contract operator
+// from a signer URL. The scheme selects the backend:
+//
+//	keystore:///path/to/key.json       decrypts a JSON keystore file using
+//	                                   the KEYSTORE_PASSPHRASE env var.
+//	clef://path/to/clef.ipc            delegates signing to a running clef
+//	                                   instance (or clef://host:port for HTTP),
+//	                                   using its first listed account unless
+//	                                   ?account=0x... picks a specific one.
+//	ledger://m/44'/60'/0'/0/0          a USB hardware wallet resolved by
+//	                                   derivation path: ledger:// opens a
+//	                                   Ledger, trezor:// a Trezor.
+//
+// runPool never needs to know which of these is in use; it just gets back a
+// TransactOpts (or a nil one, meaning read-only mode) and an ErrExplain on
+// failure.
+func resolveTransactor(signerURL string) (*bind.TransactOpts, error) {
+	if signerURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(signerURL)
+	if err != nil {
+		return nil, ErrExplain{err, fmt.Sprintf("Failed to parse --contract-signer value %q.", signerURL)}
+	}
+
+	switch u.Scheme {
+	case "keystore":
+		opts, err := unlockTransactor(keystorePath(u))
+		if err != nil {
+			return nil, ErrExplain{
+				err,
+				"Failed to unlock the keystore for the contract operator wallet. Make sure the path is correct and the decryption password is set in the `KEYSTORE_PASSPHRASE` environment variable.",
+			}
+		}
+		return opts, nil
+	case "clef":
+		return clefTransactor(u)
+	case "ledger", "trezor":
+		return usbTransactor(u.Scheme, u.Host+u.Path)
+	default:
+		return nil, ErrExplain{
+			fmt.Errorf("unrecognized contract signer scheme: %q", u.Scheme),
+			"Supported --contract-signer schemes are keystore://, clef://, ledger://, and trezor://.",
+		}
+	}
+}
+
+// keystorePath reassembles the filesystem path from a keystore:// URL.
+// Opaque covers "keystore:relative/path" (no //); url.Parse otherwise
+// treats the first path segment as Host, so "keystore://relative/path"
+// needs Host+Path; "keystore:///abs/path" is Path alone.
+func keystorePath(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	if u.Host != "" {
+		return u.Host + u.Path
+	}
+	return u.Path
+}
+
+// unlockTransactor decrypts a JSON keystore file using the passphrase from
+// the KEYSTORE_PASSPHRASE environment variable.
+func unlockTransactor(keystorePath string) (*bind.TransactOpts, error) {
+	pw := os.Getenv("KEYSTORE_PASSPHRASE")
+	r, err := os.Open(keystorePath)
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewTransactor(r, pw)
+}
+
+// clefEndpoint reassembles the rpc.Dial target from a clef:// signer URL.
+// url.Parse treats the first path segment after "//" as Host, so
+// "clef://path/to/clef.ipc" (the IPC form shown in resolveTransactor's doc
+// comment) needs Host+Path reassembled into "path/to/clef.ipc", the same
+// ambiguity keystorePath resolves for keystore://; without it, rpc.Dial
+// would see Host "path" and try to dial it over HTTP instead of opening the
+// IPC socket. "clef://host:port" (the HTTP form) has no further path
+// segments, so it's passed through with an explicit http:// scheme instead,
+// since a bare "host:port" has no scheme rpc.Dial can recognize and would
+// be treated as an IPC socket path too.
+func clefEndpoint(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	if u.Path != "" {
+		return u.Host + u.Path
+	}
+	return "http://" + u.Host
+}
+
+// clefTransactor builds a TransactOpts backed by a clef external signer
+// reachable over IPC or HTTP at the endpoint described by u.
+func clefTransactor(u *url.URL) (*bind.TransactOpts, error) {
+	clef, err := rpc.Dial(clefEndpoint(u))
+	if err != nil {
+		return nil, ErrExplain{err, fmt.Sprintf("Failed to connect to clef signer at %q. Make sure clef is running and reachable.", u)}
+	}
+
+	account, err := resolveClefAccount(clef, u.Query().Get("account"))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewClefTransactor(clef, account), nil
+}
+
+// resolveClefAccount picks the account clef should sign with: want (from a
+// "?account=0x..." query param on the signer URL) if set, otherwise the
+// first account clef reports via its account_list RPC method. TransactOpts
+// needs a concrete account up front, and clef doesn't have a notion of a
+// "default" one.
+func resolveClefAccount(clef *rpc.Client, want string) (accounts.Account, error) {
+	var addrs []common.Address
+	if err := clef.Call(&addrs, "account_list"); err != nil {
+		return accounts.Account{}, ErrExplain{err, "Failed to list accounts from the clef signer."}
+	}
+	if len(addrs) == 0 {
+		return accounts.Account{}, ErrExplain{
+			errors.New("clef signer has no accounts"),
+			"Add an account to the clef keystore before using it as a --contract-signer.",
+		}
+	}
+	if want == "" {
+		return accounts.Account{Address: addrs[0]}, nil
+	}
+	wantAddr := common.HexToAddress(want)
+	for _, addr := range addrs {
+		if addr == wantAddr {
+			return accounts.Account{Address: addr}, nil
+		}
+	}
+	return accounts.Account{}, ErrExplain{
+		fmt.Errorf("account %q not found in clef signer", want),
+		"Make sure the ?account= address in --contract-signer matches one clef manages.",
+	}
+}
+
+// usbTransactor resolves a hardware wallet account by derivation path and
+// returns a TransactOpts that signs through the device.
+func usbTransactor(kind, derivationPath string) (*bind.TransactOpts, error) {
+	path, err := accounts.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return nil, ErrExplain{err, fmt.Sprintf("Invalid derivation path %q for %s signer.", derivationPath, kind)}
+	}
+
+	var hub *usbwallet.Hub
+	switch kind {
+	case "ledger":
+		hub, err = usbwallet.NewLedgerHub()
+	case "trezor":
+		hub, err = usbwallet.NewTrezorHubWithHID()
+	}
+	if err != nil {
+		return nil, ErrExplain{err, fmt.Sprintf("Failed to access USB %s devices. Make sure the device is connected and the pool has permission to use it.", kind)}
+	}
+
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return nil, ErrExplain{
+			fmt.Errorf("no %s device found", kind),
+			fmt.Sprintf("Plug in and unlock the %s device used for contract operations.", kind),
+		}
+	}
+	wallet := wallets[0]
+	if err := wallet.Open(""); err != nil {
+		return nil, ErrExplain{err, fmt.Sprintf("Failed to open the %s device. Make sure it's unlocked and the Ethereum app is active.", kind)}
+	}
+
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		return nil, ErrExplain{err, fmt.Sprintf("Failed to derive account %s on the %s device.", derivationPath, kind)}
+	}
+
+	return &bind.TransactOpts{
+		From: account.Address,
+		Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != account.Address {
+				return nil, bind.ErrNotAuthorized
+			}
+			return wallet.SignTx(account, tx, nil)
+		},
+	}, nil
+}
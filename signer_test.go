@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestKeystorePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"keystore:///abs/path/key.json", "/abs/path/key.json"},
+		{"keystore://relative/path/key.json", "relative/path/key.json"},
+		{"keystore:relative/path/key.json", "relative/path/key.json"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) error = %v", tt.url, err)
+		}
+		if got := keystorePath(u); got != tt.want {
+			t.Errorf("keystorePath(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestClefEndpoint(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		// The IPC form from resolveTransactor's doc comment: url.Parse
+		// treats "path" as Host and "/to/clef.ipc" as Path, so this must
+		// reassemble to the relative socket path, not dial "path" over HTTP.
+		{"clef://path/to/clef.ipc", "path/to/clef.ipc"},
+		{"clef:///abs/path/clef.ipc", "/abs/path/clef.ipc"},
+		{"clef:relative/clef.ipc", "relative/clef.ipc"},
+		// The HTTP form: no path segments beyond the host, so it gets an
+		// explicit scheme rpc.Dial can recognize.
+		{"clef://127.0.0.1:8550", "http://127.0.0.1:8550"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) error = %v", tt.url, err)
+		}
+		if got := clefEndpoint(u); got != tt.want {
+			t.Errorf("clefEndpoint(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTransactorEmpty(t *testing.T) {
+	opts, err := resolveTransactor("")
+	if err != nil {
+		t.Fatalf("resolveTransactor(\"\") error = %v, want nil", err)
+	}
+	if opts != nil {
+		t.Fatalf("resolveTransactor(\"\") = %v, want nil (read-only mode)", opts)
+	}
+}
+
+func TestResolveTransactorUnrecognizedScheme(t *testing.T) {
+	_, err := resolveTransactor("trezor-usb://whatever")
+	if err == nil || !strings.Contains(err.Error(), "unrecognized contract signer scheme") {
+		t.Fatalf("resolveTransactor(unrecognized scheme) error = %v, want an \"unrecognized contract signer scheme\" error", err)
+	}
+}